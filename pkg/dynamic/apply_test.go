@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestApply(t *testing.T) {
+	tcs := map[string]struct {
+		resource    string
+		subresource []string
+		name        string
+		namespace   string
+		cluster     logicalcluster.Name
+		path        string
+		query       string
+	}{
+		"cluster scoped apply": {
+			resource: "rtest",
+			name:     "normal_apply",
+			cluster:  logicalcluster.New("ctest"),
+			path:     "/clusters/ctest/apis/gtest/vtest/rtest/normal_apply",
+			query:    "fieldManager=test-manager",
+		},
+		"cluster and namespace scoped apply": {
+			resource:  "rtest",
+			name:      "namespaced_apply",
+			namespace: "nstest",
+			cluster:   logicalcluster.New("ctest"),
+			path:      "/clusters/ctest/apis/gtest/vtest/namespaces/nstest/rtest/namespaced_apply",
+			query:     "fieldManager=test-manager",
+		},
+		"cluster scoped subresource apply": {
+			resource:    "rtest",
+			subresource: []string{"status"},
+			name:        "normal_subresource_apply",
+			cluster:     logicalcluster.New("ctest"),
+			path:        "/clusters/ctest/apis/gtest/vtest/rtest/normal_subresource_apply/status",
+			query:       "fieldManager=test-manager",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			resource := schema.GroupVersionResource{Group: "gtest", Version: "vtest", Resource: tc.resource}
+			obj := getObject("gtest/vTest", "rTest", tc.name)
+
+			cl, srv, err := getClientServer(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "PATCH" {
+					t.Errorf("Apply(%q) got HTTP method %s. wanted PATCH", name, r.Method)
+				}
+				if r.URL.Path != tc.path {
+					t.Errorf("Apply(%q) got path %s. wanted %s", name, r.URL.Path, tc.path)
+				}
+				if got := r.URL.Query().Get("fieldManager"); got != "test-manager" {
+					t.Errorf("Apply(%q) got fieldManager=%s. wanted test-manager", name, got)
+				}
+				content := r.Header.Get("Content-Type")
+				if content != string(types.ApplyPatchType) {
+					t.Errorf("Apply(%q) got Content-Type %s. wanted %s", name, content, types.ApplyPatchType)
+				}
+
+				data, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("Apply(%q) unexpected error reading body: %v", name, err)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				if _, err := w.Write(data); err != nil {
+					t.Errorf("Apply(%q) unexpected error writing response: %v", name, err)
+				}
+			})
+			if err != nil {
+				t.Fatalf("unexpected error creating client: %v", err)
+			}
+			defer srv.Close()
+
+			opts := metav1.ApplyOptions{FieldManager: "test-manager"}
+			got, err := cl.Cluster(tc.cluster).Resource(resource).Namespace(tc.namespace).Apply(context.TODO(), tc.name, obj, opts, tc.subresource...)
+			if err != nil {
+				t.Fatalf("unexpected error applying %q: %v", name, err)
+			}
+			if !reflect.DeepEqual(got, obj) {
+				t.Errorf("Apply(%q) want: %v\ngot: %v", name, obj, got)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsNameMismatch(t *testing.T) {
+	resource := schema.GroupVersionResource{Group: "gtest", Version: "vtest", Resource: "rtest"}
+	cl, srv, err := getClientServer(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("server should not have been contacted")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+	defer srv.Close()
+
+	obj := getObject("gtest/vTest", "rTest", "actual-name")
+	_, err = cl.Cluster(logicalcluster.New("ctest")).Resource(resource).Namespace("").Apply(context.TODO(), "requested-name", obj, metav1.ApplyOptions{FieldManager: "test-manager"})
+	if err == nil {
+		t.Fatal("expected an error for mismatched name, got none")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/fxamacker/cbor/v2"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ContentTypeCBOR is the media type negotiated when a ClusterDynamicClient
+// is configured with ContentConfig.ContentType set to it.
+const ContentTypeCBOR = "application/cbor"
+
+// UnstructuredCBORScheme is CBOR's analogue of
+// unstructured.UnstructuredJSONScheme: it (de)serializes
+// *unstructured.Unstructured and *unstructured.UnstructuredList without
+// needing their Go types registered in a scheme.
+var UnstructuredCBORScheme runtime.Serializer = unstructuredCBORScheme{}
+
+type unstructuredCBORScheme struct{}
+
+// cborWatchEnvelope mirrors the shape of metav1.WatchEvent for the purposes
+// of CBOR decoding, capturing the embedded object as raw CBOR bytes instead
+// of trying to unmarshal it through runtime.RawExtension.
+type cborWatchEnvelope struct {
+	Type   string          `cbor:"type"`
+	Object cbor.RawMessage `cbor:"object"`
+}
+
+func (unstructuredCBORScheme) Decode(data []byte, _ *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	switch t := into.(type) {
+	case nil, *unstructured.Unstructured, *unstructured.UnstructuredList:
+	case *metav1.WatchEvent:
+		// The watch envelope can't be cbor.Unmarshal'd straight into
+		// metav1.WatchEvent: its Object field is a runtime.RawExtension,
+		// which only knows how to capture raw bytes via UnmarshalJSON, not
+		// CBOR. Decode the envelope by hand and stash the embedded object's
+		// raw CBOR bytes in Object.Raw ourselves, the same contract
+		// RawExtension's JSON path honors, so the caller can decode it
+		// separately with this same scheme.
+		var envelope cborWatchEnvelope
+		if err := cbor.Unmarshal(data, &envelope); err != nil {
+			return nil, nil, err
+		}
+		t.Type = envelope.Type
+		t.Object = runtime.RawExtension{Raw: []byte(envelope.Object)}
+		return t, nil, nil
+	default:
+		// into is some other concrete API machinery type rather than
+		// something this scheme knows how to turn into unstructured
+		// content. Unmarshal straight into it, the same way
+		// encoding/json would.
+		if err := cbor.Unmarshal(data, into); err != nil {
+			return nil, nil, err
+		}
+		return into, nil, nil
+	}
+
+	var raw map[string]interface{}
+	if err := cbor.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+	m := normalizeCBORValue(raw).(map[string]interface{})
+
+	gvk := schema.GroupVersionKind{}
+	if apiVersion, ok := m["apiVersion"].(string); ok {
+		if gv, err := schema.ParseGroupVersion(apiVersion); err == nil {
+			gvk.Group, gvk.Version = gv.Group, gv.Version
+		}
+	}
+	if kind, ok := m["kind"].(string); ok {
+		gvk.Kind = kind
+	}
+
+	switch t := into.(type) {
+	case *unstructured.UnstructuredList:
+		t.Object = m
+		return t, &gvk, nil
+	case *unstructured.Unstructured:
+		t.Object = m
+		return t, &gvk, nil
+	}
+
+	if _, hasItems := m["items"]; hasItems {
+		return &unstructured.UnstructuredList{Object: m}, &gvk, nil
+	}
+	return &unstructured.Unstructured{Object: m}, &gvk, nil
+}
+
+func (unstructuredCBORScheme) Encode(obj runtime.Object, w io.Writer) error {
+	var (
+		data []byte
+		err  error
+	)
+	switch t := obj.(type) {
+	case *metav1.WatchEvent:
+		// Mirror Decode's special case: t.Object.Raw already holds the
+		// embedded object pre-encoded as CBOR (restclientwatch.Encoder
+		// fills it in via this same scheme), so splice it in as a raw
+		// value instead of letting cbor.Marshal struct-encode the
+		// runtime.RawExtension wrapper around it.
+		data, err = cbor.Marshal(cborWatchEnvelope{Type: t.Type, Object: cbor.RawMessage(t.Object.Raw)})
+	case runtime.Unstructured:
+		data, err = cbor.Marshal(t.UnstructuredContent())
+	default:
+		// Plain API machinery types (e.g. metav1.DeleteOptions) that don't
+		// implement runtime.Unstructured encode straight off their Go
+		// struct tags, the same way unstructured.UnstructuredJSONScheme
+		// falls back to encoding/json for them.
+		data, err = cbor.Marshal(obj)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (unstructuredCBORScheme) Identifier() runtime.Identifier {
+	return runtime.Identifier(ContentTypeCBOR)
+}
+
+// CBORFramer is CBOR's analogue of json.Framer. CBOR values are
+// self-delimiting, so writing needs no extra framing at all; reading peels
+// one self-delimited value off the stream at a time.
+var CBORFramer runtime.Framer = cborFramer{}
+
+type cborFramer struct{}
+
+func (cborFramer) NewFrameReader(r io.ReadCloser) io.ReadCloser {
+	return &cborFrameReader{closer: r, dec: cbor.NewDecoder(r)}
+}
+
+func (cborFramer) NewFrameWriter(w io.Writer) io.Writer {
+	return w
+}
+
+// cborFrameReader decodes one CBOR data item per underlying Decode call and
+// hands its raw bytes back to the caller, buffering whatever didn't fit in
+// a single Read.
+type cborFrameReader struct {
+	closer    io.Closer
+	dec       *cbor.Decoder
+	remaining []byte
+}
+
+func (f *cborFrameReader) Read(p []byte) (int, error) {
+	if len(f.remaining) == 0 {
+		var raw cbor.RawMessage
+		if err := f.dec.Decode(&raw); err != nil {
+			return 0, err
+		}
+		f.remaining = raw
+	}
+	n := copy(p, f.remaining)
+	f.remaining = f.remaining[n:]
+	return n, nil
+}
+
+func (f *cborFrameReader) Close() error {
+	return f.closer.Close()
+}
+
+// normalizeCBORValue recursively converts the map[interface{}]interface{}
+// and similar loosely-typed values cbor.Unmarshal produces for "any" targets
+// into the map[string]interface{}/[]interface{} shapes unstructured.Unstructured
+// expects, matching what encoding/json already gives us for free.
+func normalizeCBORValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprintf("%v", k)] = normalizeCBORValue(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[k] = normalizeCBORValue(val)
+		}
+		return m
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = normalizeCBORValue(val)
+		}
+		return out
+	case uint64:
+		// cbor decodes positive integers to uint64, which isn't a type
+		// unstructured.Unstructured's DeepCopy (and the rest of
+		// apimachinery) knows how to handle. encoding/json gives us int64
+		// for whole numbers, so match that; fall back to float64 only if
+		// the value doesn't fit, same as it would have lost precision
+		// through JSON anyway.
+		if t <= math.MaxInt64 {
+			return int64(t)
+		}
+		return float64(t)
+	default:
+		return v
+	}
+}
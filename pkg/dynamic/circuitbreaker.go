@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/logicalcluster"
+)
+
+// CircuitBreakerEvent is reported to a circuit breaker middleware's onEvent
+// callback whenever a per-cluster breaker opens or closes.
+type CircuitBreakerEvent struct {
+	Cluster logicalcluster.Name
+	Open    bool
+	Reason  string
+}
+
+// NewCircuitBreakerMiddleware returns a ClientMiddleware that fails fast for
+// a logical cluster once it sees threshold consecutive 5xx responses or
+// transport errors in a row for that cluster, and lets traffic back through
+// once cooldown has elapsed since it opened. onEvent, if non-nil, is called
+// whenever a cluster's breaker opens or closes; it must return quickly, as
+// it's invoked while the breaker's internal lock is held.
+func NewCircuitBreakerMiddleware(threshold int, cooldown time.Duration, onEvent func(CircuitBreakerEvent)) ClientMiddleware {
+	if onEvent == nil {
+		onEvent = func(CircuitBreakerEvent) {}
+	}
+
+	var mu sync.Mutex
+	breakers := map[logicalcluster.Name]*circuitBreakerState{}
+
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			cluster, ok := ClusterFromRequest(req)
+			if !ok {
+				return rt.RoundTrip(req)
+			}
+
+			mu.Lock()
+			state, ok := breakers[cluster]
+			if !ok {
+				state = &circuitBreakerState{}
+				breakers[cluster] = state
+			}
+			mu.Unlock()
+
+			if open, reason := state.isOpen(); open {
+				return nil, fmt.Errorf("circuit breaker open for cluster %q: %s", cluster, reason)
+			}
+
+			resp, err := rt.RoundTrip(req)
+			state.record(cluster, err != nil || (resp != nil && resp.StatusCode >= 500), threshold, cooldown, onEvent)
+			return resp, err
+		})
+	}
+}
+
+// circuitBreakerState tracks one logical cluster's consecutive-failure
+// count and, once it trips, the time its cooldown window ends.
+type circuitBreakerState struct {
+	mu               sync.Mutex
+	consecutiveFails int
+	openUntil        time.Time
+}
+
+func (s *circuitBreakerState) isOpen() (bool, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.openUntil.IsZero() && time.Now().Before(s.openUntil) {
+		return true, "consecutive failure threshold reached"
+	}
+	return false, ""
+}
+
+func (s *circuitBreakerState) record(cluster logicalcluster.Name, failed bool, threshold int, cooldown time.Duration, onEvent func(CircuitBreakerEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !failed {
+		if !s.openUntil.IsZero() {
+			onEvent(CircuitBreakerEvent{Cluster: cluster, Open: false, Reason: "request succeeded"})
+		}
+		s.consecutiveFails = 0
+		s.openUntil = time.Time{}
+		return
+	}
+
+	s.consecutiveFails++
+	if s.consecutiveFails < threshold {
+		return
+	}
+
+	wasOpen := !s.openUntil.IsZero() && time.Now().Before(s.openUntil)
+	s.openUntil = time.Now().Add(cooldown)
+	if !wasOpen {
+		onEvent(CircuitBreakerEvent{Cluster: cluster, Open: true, Reason: "consecutive failure threshold reached"})
+	}
+}
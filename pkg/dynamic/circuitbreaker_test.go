@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	restclient "k8s.io/client-go/rest"
+)
+
+func TestCircuitBreakerOpensAndCloses(t *testing.T) {
+	const threshold = 3
+	const cooldown = 50 * time.Millisecond
+
+	var requests int64
+	var failUntil int64 = threshold // first `threshold` requests fail, then succeed
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&requests, 1)
+		if n <= atomic.LoadInt64(&failUntil) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(getJSON("vTest", "rTest", "thing"))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var events []CircuitBreakerEvent
+	mw := NewCircuitBreakerMiddleware(threshold, cooldown, func(e CircuitBreakerEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+
+	cl, err := NewClusterDynamicClientForConfig(&restclient.Config{Host: srv.URL}, WithClientMiddleware(mw))
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+
+	resource := schema.GroupVersionResource{Group: "gtest", Version: "vtest", Resource: "rtest"}
+	cluster := logicalcluster.New("broken")
+	r := cl.Cluster(cluster).Resource(resource).Namespace("")
+
+	for i := 0; i < threshold; i++ {
+		if _, err := r.Get(context.TODO(), "thing", metav1.GetOptions{}); err == nil {
+			t.Errorf("request %d: expected the 503 to surface as an error", i)
+		}
+	}
+
+	// The breaker should now be open: further requests fail fast without
+	// reaching the server.
+	before := atomic.LoadInt64(&requests)
+	if _, err := r.Get(context.TODO(), "thing", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected an open-breaker error")
+	}
+	if atomic.LoadInt64(&requests) != before {
+		t.Errorf("expected the open breaker to short-circuit the request instead of hitting the server")
+	}
+
+	time.Sleep(2 * cooldown)
+
+	if _, err := r.Get(context.TODO(), "thing", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the breaker to let a request through after cooldown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || !events[0].Open || events[1].Open {
+		t.Errorf("expected an open event followed by a close event, got %+v", events)
+	}
+}
+
+func TestPerClusterRateLimiterDoesNotCrossDelayClusters(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(getJSON("vTest", "rTest", "thing"))
+	}))
+	defer srv.Close()
+
+	// Cluster "slow" gets one token per very long period with no burst, so
+	// its second request would block for a long time. Cluster "fast" must
+	// still complete quickly.
+	mw := NewPerClusterRateLimiterMiddleware(0.001, 1)
+	cl, err := NewClusterDynamicClientForConfig(&restclient.Config{Host: srv.URL}, WithClientMiddleware(mw))
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+
+	resource := schema.GroupVersionResource{Group: "gtest", Version: "vtest", Resource: "rtest"}
+
+	slow := cl.Cluster(logicalcluster.New("slow")).Resource(resource).Namespace("")
+	fast := cl.Cluster(logicalcluster.New("fast")).Resource(resource).Namespace("")
+
+	// Exhaust the "slow" cluster's single token.
+	if _, err := slow.Get(context.TODO(), "thing", metav1.GetOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Kick off a second "slow" request, which will block for a long time on
+	// its own bucket, concurrently with a "fast" request that should return
+	// immediately regardless.
+	go func() {
+		_, _ = slow.Get(context.TODO(), "thing", metav1.GetOptions{})
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := fast.Get(context.TODO(), "thing", metav1.GetOptions{}); err != nil {
+			t.Errorf("unexpected error for the fast cluster: %v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("fast cluster's request was delayed by the slow cluster's rate limit")
+	}
+}
@@ -0,0 +1,348 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+Modifications Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dynamic provides a cluster-aware variant of client-go's dynamic
+// client. Every request is scoped to a logical cluster (or to all logical
+// clusters via a wildcard) by prefixing the REST path with
+// /clusters/<name>, as served by a KCP-style API server.
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	restclient "k8s.io/client-go/rest"
+)
+
+// ClusterDynamicClient is a cluster-aware dynamic client: it behaves like
+// client-go's dynamic.Interface, except that Cluster must be called first to
+// select which logical cluster (or logicalcluster.Wildcard for all of them)
+// the subsequent request is issued against.
+type ClusterDynamicClient struct {
+	client      *restclient.RESTClient
+	contentType string
+}
+
+var _ ClusterInterface = (*ClusterDynamicClient)(nil)
+
+// NewClusterDynamicClientForConfig creates a new ClusterDynamicClient for
+// the given config. The returned client issues unstructured requests
+// against whatever logical cluster is selected via Cluster. Setting
+// inConfig.ContentType to ContentTypeCBOR makes every request prefer CBOR
+// responses (JSON responses are still decoded) and makes Create and Update
+// send CBOR bodies. Delete, DeleteCollection and Apply bodies are always
+// JSON, and Patch sends data as given. Pass WithClientMiddleware
+// to install rate limiting, circuit breaking, or other transport-level
+// behavior keyed off the request's resolved logical cluster.
+func NewClusterDynamicClientForConfig(inConfig *restclient.Config, opts ...ClientOption) (*ClusterDynamicClient, error) {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	contentType := restclientContentType
+	if inConfig.ContentType == ContentTypeCBOR {
+		contentType = ContentTypeCBOR
+	}
+
+	config := configFor(inConfig, contentType, o)
+
+	httpClient, err := restclient.HTTPClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	restClient, err := restclient.UnversionedRESTClientForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClusterDynamicClient{client: restClient, contentType: contentType}, nil
+}
+
+// configFor returns a copy of inConfig set up the way the dynamic client
+// needs it: unstructured (un)marshaling via the negotiated scheme and no
+// GroupVersion pinned, since the resource determines that per-request. Any
+// ClientMiddleware in o is chained onto the config's transport, innermost
+// wrapping closest to the network, ahead of whatever WrapTransport inConfig
+// already carried.
+func configFor(inConfig *restclient.Config, contentType string, o clientOptions) *restclient.Config {
+	config := restclient.CopyConfig(inConfig)
+	config.ContentType = contentType
+	if contentType == ContentTypeCBOR {
+		config.AcceptContentTypes = ContentTypeCBOR + ",application/json;q=0.9"
+	} else {
+		config.AcceptContentTypes = restclientContentType
+	}
+	config.NegotiatedSerializer = newJSONNegotiatedSerializer()
+	if config.UserAgent == "" {
+		config.UserAgent = restclient.DefaultKubernetesUserAgent()
+	}
+	config.WrapTransport = o.wrapTransport(config.WrapTransport)
+	return config
+}
+
+const restclientContentType = "application/json"
+
+// Cluster scopes the client to a single logical cluster. Pass
+// logicalcluster.Wildcard to address every logical cluster known to the
+// server (the server will answer with a union response and objects carrying
+// their owning cluster name).
+func (c *ClusterDynamicClient) Cluster(cluster logicalcluster.Name) Interface {
+	return &clusterClient{client: c.client, cluster: cluster, contentType: c.contentType}
+}
+
+type clusterClient struct {
+	client      *restclient.RESTClient
+	cluster     logicalcluster.Name
+	contentType string
+}
+
+func (c *clusterClient) Resource(resource schema.GroupVersionResource) NamespaceableResourceInterface {
+	return &dynamicResourceClient{client: c.client, cluster: c.cluster, resource: resource, contentType: c.contentType}
+}
+
+type dynamicResourceClient struct {
+	client      *restclient.RESTClient
+	cluster     logicalcluster.Name
+	resource    schema.GroupVersionResource
+	namespace   string
+	contentType string
+}
+
+var _ NamespaceableResourceInterface = (*dynamicResourceClient)(nil)
+
+func (c *dynamicResourceClient) Namespace(ns string) ResourceInterface {
+	ret := *c
+	ret.namespace = ns
+	return &ret
+}
+
+// clusterPathSegment returns the /clusters/<name> path segment for this
+// client's logical cluster, using "*" for logicalcluster.Wildcard.
+func (c *dynamicResourceClient) clusterPathSegment() string {
+	if c.cluster == logicalcluster.Wildcard {
+		return "*"
+	}
+	return c.cluster.String()
+}
+
+// makeURLSegments builds the REST path segments for a request against name
+// (which may be empty for collection-scoped requests), e.g.
+// ["clusters", "foo", "apis", "g", "v", "namespaces", "ns", "r", "name"].
+func (c *dynamicResourceClient) makeURLSegments(name string) []string {
+	url := []string{"clusters", c.clusterPathSegment()}
+	if len(c.resource.Group) == 0 {
+		url = append(url, "api")
+	} else {
+		url = append(url, "apis", c.resource.Group)
+	}
+	url = append(url, c.resource.Version)
+
+	if len(c.namespace) > 0 {
+		url = append(url, "namespaces", c.namespace)
+	}
+	url = append(url, strings.TrimSuffix(c.resource.Resource, "/"))
+
+	if len(name) > 0 {
+		url = append(url, name)
+	}
+
+	return url
+}
+
+func (c *dynamicResourceClient) Create(ctx context.Context, obj *unstructured.Unstructured, opts metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	outBytes, err := encodeUnstructured(c.contentType, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ""
+	if len(subresources) > 0 {
+		name = obj.GetName()
+		if name == "" {
+			return nil, fmt.Errorf("name is required to create a subresource")
+		}
+	}
+
+	result := c.client.Post().
+		AbsPath(append(c.makeURLSegments(name), subresources...)...).
+		Body(outBytes).
+		SetHeader("Content-Type", c.contentType).
+		SpecificallyVersionedParams(&opts, dynamicParameterCodec, versionV1).
+		Do(ctx)
+	if err := result.Error(); err != nil {
+		return nil, err
+	}
+
+	uncastObj, err := result.Get()
+	if err != nil {
+		return nil, err
+	}
+	return uncastObj.(*unstructured.Unstructured), nil
+}
+
+func (c *dynamicResourceClient) Update(ctx context.Context, obj *unstructured.Unstructured, opts metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	name := obj.GetName()
+	if name == "" {
+		return nil, fmt.Errorf("name is required to update an object")
+	}
+
+	outBytes, err := encodeUnstructured(c.contentType, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	result := c.client.Put().
+		AbsPath(append(c.makeURLSegments(name), subresources...)...).
+		Body(outBytes).
+		SetHeader("Content-Type", c.contentType).
+		SpecificallyVersionedParams(&opts, dynamicParameterCodec, versionV1).
+		Do(ctx)
+	if err := result.Error(); err != nil {
+		return nil, err
+	}
+
+	uncastObj, err := result.Get()
+	if err != nil {
+		return nil, err
+	}
+	return uncastObj.(*unstructured.Unstructured), nil
+}
+
+func (c *dynamicResourceClient) UpdateStatus(ctx context.Context, obj *unstructured.Unstructured, opts metav1.UpdateOptions) (*unstructured.Unstructured, error) {
+	return c.Update(ctx, obj, opts, "status")
+}
+
+func (c *dynamicResourceClient) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("name is required")
+	}
+
+	deleteBytes, err := runtime.Encode(unstructured.UnstructuredJSONScheme, &opts)
+	if err != nil {
+		return err
+	}
+
+	result := c.client.Delete().
+		AbsPath(append(c.makeURLSegments(name), subresources...)...).
+		Body(deleteBytes).
+		SetHeader("Content-Type", runtime.ContentTypeJSON).
+		Do(ctx)
+	return result.Error()
+}
+
+func (c *dynamicResourceClient) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	deleteBytes, err := runtime.Encode(unstructured.UnstructuredJSONScheme, &opts)
+	if err != nil {
+		return err
+	}
+
+	result := c.client.Delete().
+		AbsPath(c.makeURLSegments("")...).
+		Body(deleteBytes).
+		SetHeader("Content-Type", runtime.ContentTypeJSON).
+		SpecificallyVersionedParams(&listOptions, dynamicParameterCodec, versionV1).
+		Do(ctx)
+	return result.Error()
+}
+
+func (c *dynamicResourceClient) Get(ctx context.Context, name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	result := c.client.Get().
+		AbsPath(append(c.makeURLSegments(name), subresources...)...).
+		SpecificallyVersionedParams(&opts, dynamicParameterCodec, versionV1).
+		Do(ctx)
+	if err := result.Error(); err != nil {
+		return nil, err
+	}
+
+	uncastObj, err := result.Get()
+	if err != nil {
+		return nil, err
+	}
+	return uncastObj.(*unstructured.Unstructured), nil
+}
+
+func (c *dynamicResourceClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	result := c.client.Get().
+		AbsPath(c.makeURLSegments("")...).
+		SpecificallyVersionedParams(&opts, dynamicParameterCodec, versionV1).
+		Do(ctx)
+	if err := result.Error(); err != nil {
+		return nil, err
+	}
+
+	uncastObj, err := result.Get()
+	if err != nil {
+		return nil, err
+	}
+	if list, ok := uncastObj.(*unstructured.UnstructuredList); ok {
+		return list, nil
+	}
+
+	list, err := uncastObj.(*unstructured.Unstructured).ToList()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *dynamicResourceClient) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	wi, err := c.client.Get().
+		AbsPath(c.makeURLSegments("")...).
+		SpecificallyVersionedParams(&opts, dynamicParameterCodec, versionV1).
+		Watch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wi, nil
+}
+
+func (c *dynamicResourceClient) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	result := c.client.Patch(pt).
+		AbsPath(append(c.makeURLSegments(name), subresources...)...).
+		Body(data).
+		SpecificallyVersionedParams(&opts, dynamicParameterCodec, versionV1).
+		Do(ctx)
+	if err := result.Error(); err != nil {
+		return nil, err
+	}
+
+	uncastObj, err := result.Get()
+	if err != nil {
+		return nil, err
+	}
+	return uncastObj.(*unstructured.Unstructured), nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kcp-dev/logicalcluster"
+)
+
+// ClientMiddleware wraps the http.RoundTripper a ClusterDynamicClient sends
+// requests through. It runs after a request's URL has been built (so the
+// /clusters/<name>/... prefix is already resolved, see ClusterFromRequest)
+// and before the request is actually sent.
+type ClientMiddleware func(http.RoundTripper) http.RoundTripper
+
+// ClientOption configures NewClusterDynamicClientForConfig.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	middleware []ClientMiddleware
+}
+
+// WithClientMiddleware chains one or more ClientMiddlewares onto the
+// transport NewClusterDynamicClientForConfig builds. Middlewares run in the
+// order given, each wrapping the next, with the last one closest to the
+// network.
+func WithClientMiddleware(mw ...ClientMiddleware) ClientOption {
+	return func(o *clientOptions) {
+		o.middleware = append(o.middleware, mw...)
+	}
+}
+
+// wrapTransport composes opts.middleware (in order) with whatever
+// WrapTransport was already set on the config, if any.
+func (o clientOptions) wrapTransport(existing func(http.RoundTripper) http.RoundTripper) func(http.RoundTripper) http.RoundTripper {
+	if len(o.middleware) == 0 {
+		return existing
+	}
+	return func(rt http.RoundTripper) http.RoundTripper {
+		if existing != nil {
+			rt = existing(rt)
+		}
+		for i := len(o.middleware) - 1; i >= 0; i-- {
+			rt = o.middleware[i](rt)
+		}
+		return rt
+	}
+}
+
+// ClusterFromRequest parses the /clusters/<name>/... prefix off req's URL
+// path, returning the logical cluster the request was resolved against. It
+// returns false for requests that don't carry that prefix (e.g. discovery).
+func ClusterFromRequest(req *http.Request) (logicalcluster.Name, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] != "clusters" || parts[1] == "" {
+		return logicalcluster.Name{}, false
+	}
+	return logicalcluster.New(parts[1]), true
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper, the same way
+// http.HandlerFunc adapts a function to http.Handler.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
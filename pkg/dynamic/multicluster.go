@@ -0,0 +1,220 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// defaultMultiClusterConcurrency bounds how many clusters a MultiCluster
+// fan-out talks to at once when WithConcurrency isn't passed.
+const defaultMultiClusterConcurrency = 10
+
+// MultiClusterOption configures a MultiCluster fan-out.
+type MultiClusterOption func(*multiClusterClient)
+
+// WithConcurrency bounds the number of in-flight requests a MultiCluster
+// fan-out issues at once. n <= 0 is treated as 1.
+func WithConcurrency(n int) MultiClusterOption {
+	return func(c *multiClusterClient) {
+		if n <= 0 {
+			n = 1
+		}
+		c.concurrency = n
+	}
+}
+
+// MultiCluster returns a helper for fanning a single List/Get/Delete call
+// out across every cluster in clusters, bounded by a worker pool (see
+// WithConcurrency). Every call honors ctx: once it's done, clusters not yet
+// dispatched are skipped and recorded with ctx's error, and requests already
+// in flight are cancelled through ctx.
+func (c *ClusterDynamicClient) MultiCluster(clusters []logicalcluster.Name, opts ...MultiClusterOption) *multiClusterClient {
+	mc := &multiClusterClient{
+		dyn:         c,
+		clusters:    clusters,
+		concurrency: defaultMultiClusterConcurrency,
+	}
+	for _, opt := range opts {
+		opt(mc)
+	}
+	return mc
+}
+
+type multiClusterClient struct {
+	dyn         *ClusterDynamicClient
+	clusters    []logicalcluster.Name
+	concurrency int
+}
+
+func (c *multiClusterClient) Resource(resource schema.GroupVersionResource) *multiClusterResourceClient {
+	return &multiClusterResourceClient{mc: c, resource: resource}
+}
+
+type multiClusterResourceClient struct {
+	mc        *multiClusterClient
+	resource  schema.GroupVersionResource
+	namespace string
+}
+
+func (c *multiClusterResourceClient) Namespace(ns string) *multiClusterResourceClient {
+	ret := *c
+	ret.namespace = ns
+	return &ret
+}
+
+// fanOut runs do once per cluster from mc.clusters, using a worker pool
+// bounded by mc.concurrency, and collects whatever do reports for each
+// cluster under a shared lock. It returns once every cluster has either
+// completed or been abandoned because ctx was done.
+func fanOut(ctx context.Context, clusters []logicalcluster.Name, concurrency int, do func(cluster logicalcluster.Name) error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > len(clusters) {
+		concurrency = len(clusters)
+	}
+
+	work := make(chan logicalcluster.Name)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cluster := range work {
+				_ = do(cluster)
+			}
+		}()
+	}
+
+dispatch:
+	for i, cluster := range clusters {
+		select {
+		case work <- cluster:
+		case <-ctx.Done():
+			// Clusters never handed to a worker still go through do, which
+			// sees ctx is done and records its error without a request.
+			for _, skipped := range clusters[i:] {
+				_ = do(skipped)
+			}
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+}
+
+// List fans List(ctx, opts) out across every cluster passed to MultiCluster,
+// returning the per-cluster results and a separate map of per-cluster
+// errors for partial failures.
+func (c *multiClusterResourceClient) List(ctx context.Context, opts metav1.ListOptions) (map[logicalcluster.Name]*unstructured.UnstructuredList, map[logicalcluster.Name]error) {
+	results := make(map[logicalcluster.Name]*unstructured.UnstructuredList, len(c.mc.clusters))
+	errs := make(map[logicalcluster.Name]error)
+	var mu sync.Mutex
+
+	fanOut(ctx, c.mc.clusters, c.mc.concurrency, func(cluster logicalcluster.Name) error {
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			errs[cluster] = err
+			mu.Unlock()
+			return err
+		}
+		list, err := c.mc.dyn.Cluster(cluster).Resource(c.resource).Namespace(c.namespace).List(ctx, opts)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs[cluster] = err
+			return err
+		}
+		results[cluster] = list
+		return nil
+	})
+
+	return results, errs
+}
+
+// Get fans Get(ctx, name, opts) out across every cluster passed to
+// MultiCluster.
+func (c *multiClusterResourceClient) Get(ctx context.Context, name string, opts metav1.GetOptions, subresources ...string) (map[logicalcluster.Name]*unstructured.Unstructured, map[logicalcluster.Name]error) {
+	results := make(map[logicalcluster.Name]*unstructured.Unstructured, len(c.mc.clusters))
+	errs := make(map[logicalcluster.Name]error)
+	var mu sync.Mutex
+
+	fanOut(ctx, c.mc.clusters, c.mc.concurrency, func(cluster logicalcluster.Name) error {
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			errs[cluster] = err
+			mu.Unlock()
+			return err
+		}
+		obj, err := c.mc.dyn.Cluster(cluster).Resource(c.resource).Namespace(c.namespace).Get(ctx, name, opts, subresources...)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs[cluster] = err
+			return err
+		}
+		results[cluster] = obj
+		return nil
+	})
+
+	return results, errs
+}
+
+// Delete fans Delete(ctx, name, opts) out across every cluster passed to
+// MultiCluster, returning only the per-cluster errors.
+func (c *multiClusterResourceClient) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) map[logicalcluster.Name]error {
+	errs := make(map[logicalcluster.Name]error)
+	var mu sync.Mutex
+
+	fanOut(ctx, c.mc.clusters, c.mc.concurrency, func(cluster logicalcluster.Name) error {
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			errs[cluster] = err
+			mu.Unlock()
+			return err
+		}
+		err := c.mc.dyn.Cluster(cluster).Resource(c.resource).Namespace(c.namespace).Delete(ctx, name, opts, subresources...)
+		if err != nil {
+			mu.Lock()
+			errs[cluster] = err
+			mu.Unlock()
+		}
+		return err
+	})
+
+	return errs
+}
+
+// ResourceVersions extracts the per-cluster ResourceVersion out of a List
+// fan-out's results, so the caller can seed a follow-up per-cluster Watch
+// (via ListOptions.ResourceVersion) without re-deriving it from the list
+// items itself.
+func ResourceVersions(lists map[logicalcluster.Name]*unstructured.UnstructuredList) map[logicalcluster.Name]string {
+	versions := make(map[logicalcluster.Name]string, len(lists))
+	for cluster, list := range lists {
+		versions[cluster] = list.GetResourceVersion()
+	}
+	return versions
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestMultiClusterListDispatchesPerCluster(t *testing.T) {
+	clusters := []logicalcluster.Name{
+		logicalcluster.New("alpha"),
+		logicalcluster.New("beta"),
+		logicalcluster.New("gamma"),
+	}
+
+	var mu sync.Mutex
+	seenPaths := map[string]int{}
+
+	cl, srv, err := getClientServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seenPaths[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(getListJSON("vTest", "rTestList"))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating client/server: %v", err)
+	}
+	defer srv.Close()
+
+	resource := schema.GroupVersionResource{Group: "gtest", Version: "vtest", Resource: "rtest"}
+	results, errs := cl.MultiCluster(clusters).Resource(resource).Namespace("").List(context.TODO(), metav1.ListOptions{})
+
+	for _, cluster := range clusters {
+		if err, ok := errs[cluster]; ok {
+			t.Errorf("unexpected error for cluster %q: %v", cluster, err)
+		}
+		if _, ok := results[cluster]; !ok {
+			t.Errorf("missing result for cluster %q", cluster)
+		}
+	}
+
+	for _, cluster := range clusters {
+		wantPath := "/clusters/" + cluster.String() + "/apis/gtest/vtest/rtest"
+		mu.Lock()
+		count := seenPaths[wantPath]
+		mu.Unlock()
+		if count != 1 {
+			t.Errorf("expected exactly one request to %q, got %d", wantPath, count)
+		}
+	}
+}
+
+func TestMultiClusterListBoundsConcurrency(t *testing.T) {
+	clusters := make([]logicalcluster.Name, 0, 6)
+	for i := 0; i < 6; i++ {
+		clusters = append(clusters, logicalcluster.New(string(rune('a'+i))))
+	}
+
+	var inFlight, maxInFlight int64
+	release := make(chan struct{})
+
+	cl, srv, err := getClientServer(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		for {
+			cur := atomic.LoadInt64(&maxInFlight)
+			if n <= cur || atomic.CompareAndSwapInt64(&maxInFlight, cur, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt64(&inFlight, -1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(getListJSON("vTest", "rTestList"))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating client/server: %v", err)
+	}
+	defer srv.Close()
+
+	resource := schema.GroupVersionResource{Group: "gtest", Version: "vtest", Resource: "rtest"}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cl.MultiCluster(clusters, WithConcurrency(2)).Resource(resource).Namespace("").List(context.TODO(), metav1.ListOptions{})
+	}()
+
+	// Give the worker pool a chance to saturate before releasing responses.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	<-done
+
+	if got := atomic.LoadInt64(&maxInFlight); got > 2 {
+		t.Errorf("expected at most 2 concurrent requests, saw %d", got)
+	}
+}
+
+func TestMultiClusterGetReportsPartialFailure(t *testing.T) {
+	cluster := logicalcluster.New("broken")
+	clusters := []logicalcluster.Name{logicalcluster.New("ok"), cluster}
+
+	cl, srv, err := getClientServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/clusters/broken/apis/gtest/vtest/rtest/thing" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message": "boom"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(getJSON("vTest", "rTest", "thing"))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating client/server: %v", err)
+	}
+	defer srv.Close()
+
+	resource := schema.GroupVersionResource{Group: "gtest", Version: "vtest", Resource: "rtest"}
+	results, errs := cl.MultiCluster(clusters).Resource(resource).Namespace("").Get(context.TODO(), "thing", metav1.GetOptions{})
+
+	if _, ok := results[logicalcluster.New("ok")]; !ok {
+		t.Errorf("expected a result for the healthy cluster")
+	}
+	if _, ok := errs[cluster]; !ok {
+		t.Errorf("expected an error for the broken cluster")
+	}
+	if _, ok := results[cluster]; ok {
+		t.Errorf("did not expect a result for the broken cluster")
+	}
+}
+
+func TestResourceVersions(t *testing.T) {
+	cluster := logicalcluster.New("alpha")
+	lists := map[logicalcluster.Name]*unstructured.UnstructuredList{
+		cluster: {
+			Object: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"resourceVersion": "42",
+				},
+			},
+		},
+	}
+
+	versions := ResourceVersions(lists)
+	if got := versions[cluster]; got != "42" {
+		t.Errorf("got resourceVersion %q, want %q", got, "42")
+	}
+}
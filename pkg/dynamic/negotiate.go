@@ -0,0 +1,36 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// schemeFor returns the unstructured (de)serializer for contentType,
+// defaulting to JSON for anything it doesn't recognize.
+func schemeFor(contentType string) runtime.Serializer {
+	if contentType == ContentTypeCBOR {
+		return UnstructuredCBORScheme
+	}
+	return unstructured.UnstructuredJSONScheme
+}
+
+// encodeUnstructured encodes obj the way contentType asks for.
+func encodeUnstructured(contentType string, obj runtime.Object) ([]byte, error) {
+	return runtime.Encode(schemeFor(contentType), obj)
+}
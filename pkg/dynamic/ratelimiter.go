@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/logicalcluster"
+)
+
+// NewPerClusterRateLimiterMiddleware returns a ClientMiddleware that token-
+// bucket rate limits requests independently per logical cluster: ratePerSec
+// sustained requests/sec with room for a burst of burst, keyed by the
+// cluster parsed out of the request via ClusterFromRequest. Requests that
+// don't carry a /clusters/<name>/ prefix are never throttled. A slow
+// cluster never delays any other cluster's requests, since each gets its
+// own bucket.
+func NewPerClusterRateLimiterMiddleware(ratePerSec float64, burst int) ClientMiddleware {
+	var mu sync.Mutex
+	buckets := map[logicalcluster.Name]*tokenBucket{}
+
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			cluster, ok := ClusterFromRequest(req)
+			if !ok {
+				return rt.RoundTrip(req)
+			}
+
+			mu.Lock()
+			bucket, ok := buckets[cluster]
+			if !ok {
+				bucket = newTokenBucket(ratePerSec, burst)
+				buckets[cluster] = bucket
+			}
+			mu.Unlock()
+
+			if err := bucket.wait(req.Context()); err != nil {
+				return nil, err
+			}
+			return rt.RoundTrip(req)
+		})
+	}
+}
+
+// tokenBucket is a minimal token-bucket limiter: tokens refill continuously
+// at rate per second up to burst, and wait blocks until one is available.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	rate   float64
+	burst  float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		tokens: float64(burst),
+		rate:   rate,
+		burst:  float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
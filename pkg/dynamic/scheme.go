@@ -0,0 +1,102 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+Modifications Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+)
+
+var versionV1 = schema.GroupVersion{Version: "v1"}
+
+// dynamicParameterCodec knows how to encode list/get/etc. options for the
+// unstructured dynamic client, same as client-go's internal parameterCodec.
+var dynamicParameterCodec runtime.ParameterCodec
+
+// watchScheme knows about metav1.WatchEvent (and friends, like metav1.Status)
+// but nothing else. It exists so the watch envelope - which has its own
+// "type"/"object" shape, not an unstructured resource's "kind"/"apiVersion"
+// shape - can be decoded by a plain, scheme-aware JSON serializer, the same
+// way client-go's own dynamic client does it. The embedded object inside the
+// envelope is decoded separately, by the unstructured-aware serializer.
+var watchScheme = runtime.NewScheme()
+
+// watchEnvelopeSerializer decodes the metav1.WatchEvent envelope wrapping
+// every event on a watch stream. unstructured.UnstructuredJSONScheme can't
+// do this itself: it requires a top-level "kind", which a WatchEvent doesn't
+// carry, and has no way to produce a concrete *metav1.WatchEvent either way.
+var watchEnvelopeSerializer = json.NewSerializer(json.DefaultMetaFactory, watchScheme, watchScheme, false)
+
+func init() {
+	parameterScheme := runtime.NewScheme()
+	metav1.AddToGroupVersion(parameterScheme, versionV1)
+	dynamicParameterCodec = runtime.NewParameterCodec(parameterScheme)
+
+	metav1.AddToGroupVersion(watchScheme, versionV1)
+}
+
+func newJSONNegotiatedSerializer() runtime.NegotiatedSerializer {
+	return unstructuredNegotiatedSerializer{}
+}
+
+// unstructuredNegotiatedSerializer knows about the unstructured JSON and
+// CBOR schemes. It exists so the REST client has somewhere to decode Status
+// objects and stream watch events from without pulling in the full
+// generated scheme. Its name predates CBOR support; it's kept for the sake
+// of the (unexported) constructor callers already use.
+type unstructuredNegotiatedSerializer struct{}
+
+func (s unstructuredNegotiatedSerializer) SupportedMediaTypes() []runtime.SerializerInfo {
+	return []runtime.SerializerInfo{
+		{
+			MediaType:        runtime.ContentTypeJSON,
+			MediaTypeType:    "application",
+			MediaTypeSubType: "json",
+			EncodesAsText:    true,
+			Serializer:       unstructured.UnstructuredJSONScheme,
+			PrettySerializer: unstructured.UnstructuredJSONScheme,
+			StreamSerializer: &runtime.StreamSerializerInfo{
+				Serializer: watchEnvelopeSerializer,
+				Framer:     json.Framer,
+			},
+		},
+		{
+			MediaType:        ContentTypeCBOR,
+			MediaTypeType:    "application",
+			MediaTypeSubType: "cbor",
+			EncodesAsText:    false,
+			Serializer:       UnstructuredCBORScheme,
+			PrettySerializer: nil,
+			StreamSerializer: &runtime.StreamSerializerInfo{
+				Serializer: UnstructuredCBORScheme,
+				Framer:     CBORFramer,
+			},
+		},
+	}
+}
+
+func (s unstructuredNegotiatedSerializer) EncoderForVersion(encoder runtime.Encoder, _ runtime.GroupVersioner) runtime.Encoder {
+	return encoder
+}
+
+func (s unstructuredNegotiatedSerializer) DecoderToVersion(decoder runtime.Decoder, _ runtime.GroupVersioner) runtime.Decoder {
+	return decoder
+}
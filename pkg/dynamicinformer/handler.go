@@ -0,0 +1,62 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"k8s.io/client-go/tools/cache"
+)
+
+// EnqueueFunc is called with a cluster-qualified key (as produced by
+// ClusterAndNamespaceKeyFunc) whenever an informer notifies of an add,
+// update or delete. It is intended to feed a workqueue.Interface.Add.
+type EnqueueFunc func(key string)
+
+// NewClusterQueueingEventHandler returns a cache.ResourceEventHandler that
+// unpacks the logical cluster name from each object (via its
+// ClusterAnnotationKey annotation, same as the informer's own KeyFunc) and
+// calls enqueue with a cluster-qualified key, so downstream controllers
+// never need to re-derive which cluster an object came from.
+func NewClusterQueueingEventHandler(enqueue EnqueueFunc) cache.ResourceEventHandler {
+	return &clusterQueueingEventHandler{enqueue: enqueue}
+}
+
+type clusterQueueingEventHandler struct {
+	enqueue EnqueueFunc
+}
+
+func (h *clusterQueueingEventHandler) OnAdd(obj interface{}, _ bool) {
+	h.enqueueObject(obj)
+}
+
+func (h *clusterQueueingEventHandler) OnUpdate(_, newObj interface{}) {
+	h.enqueueObject(newObj)
+}
+
+func (h *clusterQueueingEventHandler) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	h.enqueueObject(obj)
+}
+
+func (h *clusterQueueingEventHandler) enqueueObject(obj interface{}) {
+	key, err := ClusterAndNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	h.enqueue(key)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fabianvf/apimachinery/pkg/dynamic"
+)
+
+// TweakListOptionsFunc lets callers adjust the ListOptions (e.g. to add a
+// label or field selector) used by every List/Watch call an informer makes.
+type TweakListOptionsFunc func(*metav1.ListOptions)
+
+// SharedIndexInformer is the subset of cache.SharedIndexInformer that
+// ClusterGenericInformer exposes. It is a distinct, smaller interface
+// because the underlying store is keyed by ClusterAndNamespaceKeyFunc rather
+// than cache.MetaNamespaceKeyFunc.
+type SharedIndexInformer interface {
+	AddEventHandler(handler cache.ResourceEventHandler) error
+	GetIndexer() cache.Indexer
+	HasSynced() bool
+	Run(stopCh <-chan struct{})
+}
+
+// clusterSharedIndexInformer is a deliberately minimal re-implementation of
+// cache.sharedIndexInformer: a Reflector feeding a DeltaFIFO keyed by
+// ClusterAndNamespaceKeyFunc, fanning deltas out to an Indexer and any
+// registered event handlers.
+type clusterSharedIndexInformer struct {
+	listWatch  *cache.ListWatch
+	objectType runtime.Object
+	resync     time.Duration
+
+	indexer    cache.Indexer
+	controller cache.Controller
+
+	handlersMu sync.RWMutex
+	handlers   []cache.ResourceEventHandler
+
+	startedMu sync.Mutex
+	started   bool
+}
+
+func newClusterSharedIndexInformer(lw *cache.ListWatch, resyncPeriod time.Duration, indexers cache.Indexers) *clusterSharedIndexInformer {
+	s := &clusterSharedIndexInformer{
+		listWatch:  lw,
+		objectType: &unstructured.Unstructured{},
+		resync:     resyncPeriod,
+		indexer:    cache.NewIndexer(DeletionHandlingClusterAndNamespaceKeyFunc, indexers),
+	}
+
+	fifo := cache.NewDeltaFIFOWithOptions(cache.DeltaFIFOOptions{
+		KeyFunction:  DeletionHandlingClusterAndNamespaceKeyFunc,
+		KnownObjects: s.indexer,
+	})
+
+	s.controller = cache.New(&cache.Config{
+		Queue:            fifo,
+		ListerWatcher:    lw,
+		ObjectType:       s.objectType,
+		FullResyncPeriod: resyncPeriod,
+		RetryOnError:     false,
+		Process:          s.handleDeltas,
+	})
+
+	return s
+}
+
+func (s *clusterSharedIndexInformer) handleDeltas(obj interface{}, isInInitialList bool) error {
+	for _, d := range obj.(cache.Deltas) {
+		switch d.Type {
+		case cache.Sync, cache.Replaced, cache.Added, cache.Updated:
+			old, exists, err := s.indexer.Get(d.Object)
+			if err != nil {
+				return err
+			}
+			if exists {
+				if err := s.indexer.Update(d.Object); err != nil {
+					return err
+				}
+				s.notify(func(h cache.ResourceEventHandler) { h.OnUpdate(old, d.Object) })
+			} else {
+				if err := s.indexer.Add(d.Object); err != nil {
+					return err
+				}
+				s.notify(func(h cache.ResourceEventHandler) { h.OnAdd(d.Object, isInInitialList) })
+			}
+		case cache.Deleted:
+			if err := s.indexer.Delete(d.Object); err != nil {
+				return err
+			}
+			s.notify(func(h cache.ResourceEventHandler) { h.OnDelete(d.Object) })
+		}
+	}
+	return nil
+}
+
+func (s *clusterSharedIndexInformer) notify(fn func(cache.ResourceEventHandler)) {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	for _, h := range s.handlers {
+		fn(h)
+	}
+}
+
+func (s *clusterSharedIndexInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.handlers = append(s.handlers, handler)
+	return nil
+}
+
+func (s *clusterSharedIndexInformer) GetIndexer() cache.Indexer {
+	return s.indexer
+}
+
+func (s *clusterSharedIndexInformer) HasSynced() bool {
+	return s.controller != nil && s.controller.HasSynced()
+}
+
+func (s *clusterSharedIndexInformer) Run(stopCh <-chan struct{}) {
+	s.startedMu.Lock()
+	if s.started {
+		s.startedMu.Unlock()
+		return
+	}
+	s.started = true
+	s.startedMu.Unlock()
+
+	s.controller.Run(stopCh)
+}
+
+// newFilteredUnstructuredInformer builds a SharedIndexInformer that lists
+// and watches resource. If cluster is logicalcluster.Wildcard the requests
+// go out against /clusters/*/... and aggregate every logical cluster the
+// server knows about; otherwise they are pinned to /clusters/<cluster>/....
+func newFilteredUnstructuredInformer(
+	client *dynamic.ClusterDynamicClient,
+	resource schema.GroupVersionResource,
+	cluster logicalcluster.Name,
+	namespace string,
+	resyncPeriod time.Duration,
+	indexers cache.Indexers,
+	tweakListOptions TweakListOptionsFunc,
+) SharedIndexInformer {
+	ctx := context.Background()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			if tweakListOptions != nil {
+				tweakListOptions(&options)
+			}
+			return client.Cluster(cluster).Resource(resource).Namespace(namespace).List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			if tweakListOptions != nil {
+				tweakListOptions(&options)
+			}
+			return client.Cluster(cluster).Resource(resource).Namespace(namespace).Watch(ctx, options)
+		},
+	}
+
+	return newClusterSharedIndexInformer(lw, resyncPeriod, indexers)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/streaming"
+	"k8s.io/apimachinery/pkg/watch"
+	restclient "k8s.io/client-go/rest"
+	restclientwatch "k8s.io/client-go/rest/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fabianvf/apimachinery/pkg/dynamic"
+)
+
+// TestClusterSharedIndexInformer drives a clusterSharedIndexInformer against
+// an httptest server that serves an initial List, a Modified event over the
+// watch stream, and then closes the stream - forcing a relist whose response
+// silently drops an object the watch never reported as deleted. This
+// exercises the same add/update/delete path real KCP informers hit, and in
+// particular the tombstone handling the relist-detected deletion requires.
+func TestClusterSharedIndexInformer(t *testing.T) {
+	resource := schema.GroupVersionResource{Group: "gtest", Version: "vtest", Resource: "rtest"}
+
+	var mu sync.Mutex
+	listCalls := 0
+	watchCalls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			mu.Lock()
+			watchCalls++
+			call := watchCalls
+			mu.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			if call == 1 {
+				updatedFoo := withCluster("cluster1", "", "foo")
+				updatedFoo.SetAPIVersion("gtest/vTest")
+				updatedFoo.SetKind("rTest")
+				updatedFoo.SetLabels(map[string]string{"updated": "true"})
+				enc := restclientwatch.NewEncoder(streaming.NewEncoder(w, unstructured.UnstructuredJSONScheme), unstructured.UnstructuredJSONScheme)
+				event := watch.Event{Type: watch.Modified, Object: updatedFoo}
+				if err := enc.Encode(&event); err != nil {
+					t.Errorf("unexpected error encoding watch event: %v", err)
+				}
+				if f, ok := w.(http.Flusher); ok {
+					f.Flush()
+				}
+			}
+			// Close the stream without sending anything further. The
+			// reflector treats this as the watch ending and relists.
+			return
+		}
+
+		mu.Lock()
+		listCalls++
+		call := listCalls
+		mu.Unlock()
+
+		list := &unstructured.UnstructuredList{}
+		list.SetAPIVersion("gtest/vTest")
+		list.SetKind("rTestList")
+		list.SetResourceVersion("1")
+		foo := withCluster("cluster1", "", "foo")
+		foo.SetAPIVersion("gtest/vTest")
+		foo.SetKind("rTest")
+		list.Items = append(list.Items, *foo)
+		if call == 1 {
+			// Only the first List response includes "bar"; every relist
+			// after that omits it without the watch ever reporting its
+			// deletion, simulating a deletion missed while disconnected.
+			bar := withCluster("cluster1", "", "bar")
+			bar.SetAPIVersion("gtest/vTest")
+			bar.SetKind("rTest")
+			list.Items = append(list.Items, *bar)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := unstructured.UnstructuredJSONScheme.Encode(list, w); err != nil {
+			t.Errorf("unexpected error writing list: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	cl, err := dynamic.NewClusterDynamicClientForConfig(&restclient.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+
+	informer := newFilteredUnstructuredInformer(
+		cl, resource, logicalcluster.New("cluster1"), "", 0,
+		cache.Indexers{ClusterIndexName: ClusterIndexFunc},
+		nil,
+	)
+
+	var handlerMu sync.Mutex
+	var adds, updates, deletes int
+	var deletedObjs []interface{}
+	if err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			handlerMu.Lock()
+			adds++
+			handlerMu.Unlock()
+		},
+		UpdateFunc: func(old, new interface{}) {
+			handlerMu.Lock()
+			updates++
+			handlerMu.Unlock()
+		},
+		DeleteFunc: func(obj interface{}) {
+			handlerMu.Lock()
+			deletes++
+			deletedObjs = append(deletedObjs, obj)
+			handlerMu.Unlock()
+		},
+	}); err != nil {
+		t.Fatalf("unexpected error adding handler: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		t.Fatal("cache never synced")
+	}
+
+	waitForCondition(t, 2*time.Second, func() bool { return len(informer.GetIndexer().List()) == 2 })
+
+	handlerMu.Lock()
+	if adds != 2 {
+		t.Errorf("got %d adds after initial sync, want 2", adds)
+	}
+	handlerMu.Unlock()
+
+	waitForCondition(t, 2*time.Second, func() bool {
+		handlerMu.Lock()
+		defer handlerMu.Unlock()
+		return updates >= 1
+	})
+
+	// The relist takes a moment to kick in (the reflector backs off before
+	// retrying after the watch closes).
+	waitForCondition(t, 10*time.Second, func() bool { return len(informer.GetIndexer().List()) == 1 })
+
+	handlerMu.Lock()
+	gotDeletes := deletes
+	lastDeleted := deletedObjs
+	handlerMu.Unlock()
+
+	if gotDeletes == 0 {
+		t.Fatalf("expected at least one OnDelete for the relist-detected deletion of %q, got none", "bar")
+	}
+
+	name := deletedObjectName(t, lastDeleted[len(lastDeleted)-1])
+	if name != "bar" {
+		t.Errorf("OnDelete fired for %q, want %q", name, "bar")
+	}
+
+	if _, exists, err := informer.GetIndexer().GetByKey("cluster1/bar"); err != nil {
+		t.Errorf("unexpected error reading indexer: %v", err)
+	} else if exists {
+		t.Errorf("indexer still has %q after the relist-detected deletion", "cluster1/bar")
+	}
+}
+
+// deletedObjectName returns obj's name, unwrapping a
+// cache.DeletedFinalStateUnknown tombstone if that's what was delivered -
+// the same thing a real event handler has to do.
+func deletedObjectName(t *testing.T, obj interface{}) string {
+	t.Helper()
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		t.Fatalf("deleted object has no meta: %v", err)
+	}
+	return accessor.GetName()
+}
+
+func waitForCondition(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !cond() {
+		t.Fatalf("condition not met within %s", timeout)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ClusterIndexName is the name under which every informer built by this
+// package registers an index keyed by logical cluster name, so callers can
+// cheaply enumerate everything known about one cluster via ByCluster.
+const ClusterIndexName = "cluster"
+
+// ClusterAnnotationKey is the annotation a KCP-style API server stamps onto
+// every object returned from a wildcard List/Watch, recording which logical
+// cluster the object actually lives in. logicalcluster.Name has no notion of
+// object metadata (From requires a GetClusterName() method metav1.Object
+// doesn't implement), so this package reads the cluster name straight off
+// the annotation instead.
+const ClusterAnnotationKey = "kcp.dev/cluster"
+
+// clusterFromAccessor returns the logical cluster name stamped on accessor
+// via ClusterAnnotationKey, or the empty Name if it isn't set.
+func clusterFromAccessor(accessor metav1.Object) logicalcluster.Name {
+	return logicalcluster.New(accessor.GetAnnotations()[ClusterAnnotationKey])
+}
+
+// ClusterAndNamespaceKeyFunc is the cache.KeyFunc used by informers in this
+// package. Unlike cache.MetaNamespaceKeyFunc, it folds in the object's
+// logical cluster so that identically-named objects from different clusters
+// never collide in the store. Keys look like "<cluster>/<namespace>/<name>"
+// for namespaced objects, or "<cluster>/<name>" for cluster-scoped ones.
+func ClusterAndNamespaceKeyFunc(obj interface{}) (string, error) {
+	if key, ok := obj.(cache.ExplicitKey); ok {
+		return string(key), nil
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", fmt.Errorf("object has no meta: %w", err)
+	}
+	cluster := clusterFromAccessor(accessor)
+	if ns := accessor.GetNamespace(); len(ns) > 0 {
+		return cluster.String() + "/" + ns + "/" + accessor.GetName(), nil
+	}
+	return cluster.String() + "/" + accessor.GetName(), nil
+}
+
+// DeletionHandlingClusterAndNamespaceKeyFunc is ClusterAndNamespaceKeyFunc,
+// but also handles cache.DeletedFinalStateUnknown tombstones the way
+// cache.DeletionHandlingMetaNamespaceKeyFunc does for client-go's own
+// informers. A relist that detects a deletion the watch missed hands
+// handleDeltas a tombstone rather than the original object; without this,
+// ClusterAndNamespaceKeyFunc's meta.Accessor call fails on it, the indexer
+// delete errors out, and the deletion is silently dropped. This is what the
+// indexer and DeltaFIFO are keyed with instead of the bare key func.
+func DeletionHandlingClusterAndNamespaceKeyFunc(obj interface{}) (string, error) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return d.Key, nil
+	}
+	return ClusterAndNamespaceKeyFunc(obj)
+}
+
+// SplitClusterNamespaceKey is the inverse of ClusterAndNamespaceKeyFunc.
+func SplitClusterNamespaceKey(key string) (cluster logicalcluster.Name, namespace, name string, err error) {
+	parts := strings.Split(key, "/")
+	switch len(parts) {
+	case 2:
+		return logicalcluster.New(parts[0]), "", parts[1], nil
+	case 3:
+		return logicalcluster.New(parts[0]), parts[1], parts[2], nil
+	default:
+		return logicalcluster.Name{}, "", "", fmt.Errorf("unexpected key format: %q", key)
+	}
+}
+
+// ClusterIndexFunc is the cache.IndexFunc registered under ClusterIndexName.
+func ClusterIndexFunc(obj interface{}) ([]string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, fmt.Errorf("object has no meta: %w", err)
+	}
+	return []string{clusterFromAccessor(accessor).String()}, nil
+}
+
+// ByCluster returns every object in indexer belonging to cluster, using the
+// pre-registered ClusterIndexName index.
+func ByCluster(indexer cache.Indexer, cluster logicalcluster.Name) ([]interface{}, error) {
+	return indexer.ByIndex(ClusterIndexName, cluster.String())
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"testing"
+
+	"github.com/kcp-dev/logicalcluster"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func withCluster(cluster, namespace, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+	}}
+	if namespace != "" {
+		u.SetNamespace(namespace)
+	}
+	u.SetAnnotations(map[string]string{ClusterAnnotationKey: cluster})
+	return u
+}
+
+func TestClusterAndNamespaceKeyFunc(t *testing.T) {
+	tcs := map[string]struct {
+		obj     *unstructured.Unstructured
+		want    string
+		wantErr bool
+	}{
+		"cluster scoped": {
+			obj:  withCluster("cluster1", "", "foo"),
+			want: "cluster1/foo",
+		},
+		"cluster and namespace scoped": {
+			obj:  withCluster("cluster1", "ns1", "foo"),
+			want: "cluster1/ns1/foo",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got, err := ClusterAndNamespaceKeyFunc(tc.obj)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got key %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitClusterNamespaceKey(t *testing.T) {
+	tcs := map[string]struct {
+		key           string
+		wantCluster   logicalcluster.Name
+		wantNamespace string
+		wantName      string
+		wantErr       bool
+	}{
+		"cluster scoped": {
+			key:         "cluster1/foo",
+			wantCluster: logicalcluster.New("cluster1"),
+			wantName:    "foo",
+		},
+		"cluster and namespace scoped": {
+			key:           "cluster1/ns1/foo",
+			wantCluster:   logicalcluster.New("cluster1"),
+			wantNamespace: "ns1",
+			wantName:      "foo",
+		},
+		"malformed": {
+			key:     "not-a-valid-key/a/b/c",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			cluster, ns, n, err := SplitClusterNamespaceKey(tc.key)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cluster != tc.wantCluster || ns != tc.wantNamespace || n != tc.wantName {
+				t.Errorf("got (%s, %s, %s), want (%s, %s, %s)", cluster, ns, n, tc.wantCluster, tc.wantNamespace, tc.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"github.com/kcp-dev/logicalcluster"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ClusterLister is a GenericLister factory scoped down to one logical
+// cluster at a time, backed by a single wildcard informer's indexer.
+type ClusterLister interface {
+	ByCluster(cluster logicalcluster.Name) cache.GenericLister
+}
+
+type clusterLister struct {
+	indexer  cache.Indexer
+	resource schema.GroupVersionResource
+}
+
+// NewClusterLister returns a ClusterLister over indexer, which must have
+// ClusterIndexName registered (every informer built by this package
+// registers it automatically).
+func NewClusterLister(indexer cache.Indexer, resource schema.GroupVersionResource) ClusterLister {
+	return &clusterLister{indexer: indexer, resource: resource}
+}
+
+func (l *clusterLister) ByCluster(cluster logicalcluster.Name) cache.GenericLister {
+	return &clusterScopedLister{indexer: l.indexer, resource: l.resource, cluster: cluster}
+}
+
+// clusterScopedLister implements cache.GenericLister/cache.GenericNamespaceLister
+// for a single logical cluster, using the "cluster" index to avoid scanning
+// objects that belong to other clusters.
+type clusterScopedLister struct {
+	indexer   cache.Indexer
+	resource  schema.GroupVersionResource
+	cluster   logicalcluster.Name
+	namespace string
+}
+
+func (l *clusterScopedLister) List(selector labels.Selector) ([]runtime.Object, error) {
+	objs, err := ByCluster(l.indexer, l.cluster)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]runtime.Object, 0, len(objs))
+	for _, obj := range objs {
+		u := obj.(*unstructured.Unstructured)
+		if l.namespace != "" && u.GetNamespace() != l.namespace {
+			continue
+		}
+		if selector.Matches(labels.Set(u.GetLabels())) {
+			ret = append(ret, u)
+		}
+	}
+	return ret, nil
+}
+
+func (l *clusterScopedLister) Get(name string) (runtime.Object, error) {
+	key := l.cluster.String() + "/"
+	if l.namespace != "" {
+		key += l.namespace + "/"
+	}
+	key += name
+
+	obj, exists, err := l.indexer.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound(l.resource.GroupResource(), name)
+	}
+	return obj.(*unstructured.Unstructured), nil
+}
+
+func (l *clusterScopedLister) ByNamespace(namespace string) cache.GenericNamespaceLister {
+	ret := *l
+	ret.namespace = namespace
+	return &ret
+}